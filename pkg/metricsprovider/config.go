@@ -0,0 +1,126 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/template"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"sigs.k8s.io/yaml"
+)
+
+// env variable that points at a MetricsConfig YAML file. When unset, promConfigDefault is used.
+const promConfigFileKey = "PROM_METRICS_CONFIG_FILE"
+
+// MetricSpec describes a single PromQL query to run per aggregation method, and how to
+// turn its result into a watcher.Metric.
+type MetricSpec struct {
+	// Name is a human-readable identifier for this spec, used in logs and as the "metric"
+	// label of load-watcher's own fetch metrics.
+	Name string `json:"name"`
+	// Type is the watcher.MetricType recorded on every watcher.Metric this spec produces.
+	Type watcher.MetricType `json:"type"`
+	// Query is a PromQL expression template. It is rendered once per entry in Methods,
+	// with "{{.Method}}" substituted for the aggregation method and "{{.Window}}"
+	// substituted for the requested watcher.Window.Duration.
+	Query string `json:"query"`
+	// Methods lists the aggregation methods (e.g. "avg_over_time", "stddev_over_time")
+	// this spec is evaluated for. promClient sets Metric.Name to the method;
+	// promForecastClient names every metric it produces "forecast".
+	Methods []string `json:"methods"`
+	// HostLabel is the PromQL label whose value identifies the host a sample came from.
+	// Defaults to "instance" when empty.
+	HostLabel string `json:"hostLabel"`
+}
+
+// MetricsConfig is the top level structure of the PROM_METRICS_CONFIG_FILE document.
+type MetricsConfig struct {
+	Metrics []MetricSpec `json:"metrics"`
+}
+
+// promConfigDefault reproduces load-watcher's original hardcoded CPU/Memory queries,
+// and is used whenever PROM_METRICS_CONFIG_FILE is not set.
+var promConfigDefault = MetricsConfig{
+	Metrics: []MetricSpec{
+		{
+			Name:      "cpu",
+			Type:      watcher.CPU,
+			Query:     "{{.Method}}(instance:node_cpu:ratio[{{.Window}}])",
+			Methods:   []string{prom_avg_method, prom_std_method},
+			HostLabel: hostLabel,
+		},
+		{
+			Name:      "memory",
+			Type:      watcher.Memory,
+			Query:     "{{.Method}}(instance:node_memory_utilisation:ratio[{{.Window}}])",
+			Methods:   []string{prom_avg_method, prom_std_method},
+			HostLabel: hostLabel,
+		},
+	},
+}
+
+// loadMetricsConfig reads the MetricsConfig pointed to by PROM_METRICS_CONFIG_FILE, falling
+// back to promConfigDefault when the env var is unset.
+func loadMetricsConfig() (MetricsConfig, error) {
+	path, present := os.LookupEnv(promConfigFileKey)
+	if !present {
+		return promConfigDefault, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return MetricsConfig{}, fmt.Errorf("unable to read %s: %v", promConfigFileKey, err)
+	}
+
+	var cfg MetricsConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return MetricsConfig{}, fmt.Errorf("unable to parse %s: %v", promConfigFileKey, err)
+	}
+
+	for i := range cfg.Metrics {
+		if cfg.Metrics[i].HostLabel == "" {
+			cfg.Metrics[i].HostLabel = hostLabel
+		}
+	}
+
+	return cfg, nil
+}
+
+// queryTemplateData is the context used to render a MetricSpec.Query template.
+type queryTemplateData struct {
+	Method string
+	Window string
+}
+
+// renderQuery executes spec's Query template for a single aggregation method and window.
+func (spec MetricSpec) renderQuery(method string, window string) (string, error) {
+	tmpl, err := template.New(spec.Name).Parse(spec.Query)
+	if err != nil {
+		return "", fmt.Errorf("invalid query template for metric %q: %v", spec.Name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, queryTemplateData{Method: method, Window: window}); err != nil {
+		return "", fmt.Errorf("unable to render query template for metric %q: %v", spec.Name, err)
+	}
+
+	return buf.String(), nil
+}
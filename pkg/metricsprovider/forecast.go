@@ -0,0 +1,199 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// env variables tuning the Holt-Winters forecast; all are optional.
+	forecastHistoryKey = "FORECAST_HISTORY_WINDOW"
+	forecastStepKey    = "FORECAST_STEP"
+	forecastAlphaKey   = "FORECAST_ALPHA"
+	forecastBetaKey    = "FORECAST_BETA"
+	forecastGammaKey   = "FORECAST_GAMMA"
+
+	forecastHistoryDefault = 2 * time.Hour
+	forecastStepDefault    = 30 * time.Second
+	forecastAlphaDefault   = 0.3
+	forecastBetaDefault    = 0.1
+	forecastGammaDefault   = 0.1
+
+	// seasonalPeriodBasis is the amount of history one seasonal cycle spans.
+	seasonalPeriodBasis = time.Hour
+
+	forecastMetricName = "forecast"
+)
+
+// promForecastClient is a watcher.FetcherClient that reports a Holt-Winters forecast of
+// the next window's value for each configured MetricSpec, rather than its raw rollup.
+type promForecastClient struct {
+	api     v1.API
+	metrics []MetricSpec
+	history time.Duration
+	step    time.Duration
+	alpha   float64
+	beta    float64
+	gamma   float64
+}
+
+func NewPromForecastClient() (watcher.FetcherClient, error) {
+	promAPI, err := newPromAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	metricsConfig, err := loadMetricsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	step := durationEnv(forecastStepKey, forecastStepDefault)
+	if step <= 0 {
+		log.Warnf("%s must be positive, using default %s", forecastStepKey, forecastStepDefault)
+		step = forecastStepDefault
+	}
+
+	return promForecastClient{
+		api:     promAPI,
+		metrics: metricsConfig.Metrics,
+		history: durationEnv(forecastHistoryKey, forecastHistoryDefault),
+		step:    step,
+		alpha:   floatEnv(forecastAlphaKey, forecastAlphaDefault),
+		beta:    floatEnv(forecastBetaKey, forecastBetaDefault),
+		gamma:   floatEnv(forecastGammaKey, forecastGammaDefault),
+	}, nil
+}
+
+// FetchAllHostsMetrics forecasts, per host and MetricSpec, the value expected over the
+// next window rather than reporting what was actually observed over the last one.
+func (s promForecastClient) FetchAllHostsMetrics(ctx context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
+	horizon, err := time.ParseDuration(window.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window duration %q: %v", window.Duration, err)
+	}
+	steps := int(math.Ceil(horizon.Seconds() / s.step.Seconds()))
+	if steps < 1 {
+		steps = 1
+	}
+	seasonalPeriod := int(seasonalPeriodBasis / s.step)
+
+	now := time.Now()
+	promRange := v1.Range{Start: now.Add(-s.history), End: now, Step: s.step}
+
+	hostMetrics := make(map[string][]watcher.Metric)
+
+	for _, spec := range s.metrics {
+		for _, method := range spec.Methods {
+			promQL, err := spec.renderQuery(method, window.Duration)
+			if err != nil {
+				log.Errorf("%v", err)
+				continue
+			}
+			hostMetrics = s.forecastAllHostMetrics(ctx, hostMetrics, spec, promQL, method, promRange, seasonalPeriod, steps, window.Duration)
+		}
+	}
+
+	return hostMetrics, nil
+}
+
+func (s promForecastClient) forecastAllHostMetrics(ctx context.Context, hostMetrics map[string][]watcher.Metric, spec MetricSpec, promQL string, method string, promRange v1.Range, seasonalPeriod int, steps int, rollup string) map[string][]watcher.Metric {
+	start := time.Now()
+	result, warnings, err := s.api.QueryRange(ctx, promQL, promRange)
+	observeFetch(spec.Name, method, start, err)
+	if err != nil {
+		log.Errorf("prometheus range query %q failed: %v", promQL, err)
+		return hostMetrics
+	}
+	if len(warnings) > 0 {
+		log.Warnf("prometheus range query %q returned warnings: %v", promQL, warnings)
+	}
+
+	matrix, ok := result.(model.Matrix)
+	if !ok {
+		log.Warnf("prometheus range query %q returned an unexpected value type: %T", promQL, result)
+		return hostMetrics
+	}
+
+	for _, stream := range matrix {
+		if len(stream.Values) == 0 {
+			continue
+		}
+
+		series := interpolate(toFloatSeries(stream.Values))
+		forecast := holtWinters(series, seasonalPeriod, steps, s.alpha, s.beta, s.gamma)
+
+		curMetric := watcher.Metric{
+			Name:   forecastMetricName,
+			Type:   spec.Type,
+			Rollup: rollup,
+			Value:  forecast,
+		}
+		host := string(stream.Metric[model.LabelName(spec.HostLabel)])
+		if host == "" {
+			log.Warnf("metric %q: result is missing host label %q, value will be bucketed under an empty host", spec.Name, spec.HostLabel)
+		}
+		hostMetrics[host] = append(hostMetrics[host], curMetric)
+	}
+
+	return hostMetrics
+}
+
+func toFloatSeries(pairs []model.SamplePair) []float64 {
+	series := make([]float64, len(pairs))
+	for i, p := range pairs {
+		series[i] = float64(p.Value)
+	}
+	return series
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	raw, present := os.LookupEnv(key)
+	if !present {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Warnf("invalid value %q for %s, using default %s: %v", raw, key, def, err)
+		return def
+	}
+	return d
+}
+
+func floatEnv(key string, def float64) float64 {
+	raw, present := os.LookupEnv(key)
+	if !present {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Warnf("invalid value %q for %s, using default %v: %v", raw, key, def, err)
+		return def
+	}
+	return v
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import "math"
+
+// holtWinters forecasts the value h steps past the end of y using multiplicative
+// triple exponential smoothing with seasonal period m. When y is too short to fit two
+// full seasonal cycles (len(y) < 2*m), it falls back to double exponential smoothing.
+func holtWinters(y []float64, m int, h int, alpha, beta, gamma float64) float64 {
+	if len(y) == 0 {
+		return 0
+	}
+	if m < 1 || len(y) < 2*m {
+		return math.Max(0, doubleExponentialSmoothing(y, h, alpha, beta))
+	}
+
+	n := len(y)
+	level := mean(y[:m])
+	trend := (mean(y[m:2*m]) - mean(y[:m])) / float64(m)
+
+	seasonal := make([]float64, n)
+	for i := 0; i < m; i++ {
+		seasonal[i] = seasonalRatio(y[i], level)
+	}
+
+	l, b := level, trend
+	for t := m; t < n; t++ {
+		prevL := l
+		s := seasonal[t-m]
+		l = alpha*(y[t]/nonZero(s)) + (1-alpha)*(prevL+b)
+		b = beta*(l-prevL) + (1-beta)*b
+		seasonal[t] = gamma*seasonalRatio(y[t], l) + (1-gamma)*s
+	}
+
+	idx := n - 1 - m + h
+	for idx >= n {
+		idx -= m
+	}
+
+	return math.Max(0, (l+float64(h)*b)*seasonal[idx])
+}
+
+// doubleExponentialSmoothing forecasts the value h steps past the end of y using Holt's
+// linear (non-seasonal) method, for series too short to support a seasonal fit.
+func doubleExponentialSmoothing(y []float64, h int, alpha, beta float64) float64 {
+	if len(y) == 1 {
+		return y[0]
+	}
+
+	l := y[0]
+	b := y[1] - y[0]
+	for t := 1; t < len(y); t++ {
+		prevL := l
+		l = alpha*y[t] + (1-alpha)*(prevL+b)
+		b = beta*(l-prevL) + (1-beta)*b
+	}
+
+	return l + float64(h)*b
+}
+
+// interpolate fills NaN gaps in y by linear interpolation between the nearest valid
+// neighbours, carrying the nearest valid value into any leading or trailing gap.
+func interpolate(y []float64) []float64 {
+	out := make([]float64, len(y))
+	copy(out, y)
+
+	for i, v := range out {
+		if !math.IsNaN(v) {
+			continue
+		}
+
+		prev, prevOk := lastValid(out, i-1, -1)
+		next, nextOk := lastValid(out, i+1, len(out))
+
+		switch {
+		case prevOk && nextOk:
+			frac := float64(i-prev) / float64(next-prev)
+			out[i] = out[prev] + frac*(out[next]-out[prev])
+		case prevOk:
+			out[i] = out[prev]
+		case nextOk:
+			out[i] = out[next]
+		default:
+			out[i] = 0
+		}
+	}
+
+	return out
+}
+
+// lastValid scans from start towards (but excluding) stop and returns the index of the
+// first non-NaN value found, or ok=false if none exists.
+func lastValid(y []float64, start int, stop int) (int, bool) {
+	step := 1
+	if stop < start {
+		step = -1
+	}
+	for i := start; i != stop; i += step {
+		if i < 0 || i >= len(y) {
+			return 0, false
+		}
+		if !math.IsNaN(y[i]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func mean(y []float64) float64 {
+	var sum float64
+	for _, v := range y {
+		sum += v
+	}
+	return sum / float64(len(y))
+}
+
+// seasonalRatio computes the multiplicative seasonal ratio of value over level, treating
+// a zero level as a neutral (no-op) seasonal factor.
+func seasonalRatio(value, level float64) float64 {
+	if level == 0 {
+		return 1
+	}
+	return value / level
+}
+
+func nonZero(v float64) float64 {
+	if v == 0 {
+		return 1
+	}
+	return v
+}
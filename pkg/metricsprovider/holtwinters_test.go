@@ -0,0 +1,114 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"math"
+	"testing"
+)
+
+const forecastTolerance = 1e-9
+
+func TestHoltWinters(t *testing.T) {
+	tests := []struct {
+		name  string
+		y     []float64
+		m     int
+		h     int
+		alpha float64
+		beta  float64
+		gamma float64
+		want  float64
+	}{
+		{
+			name:  "flat series has no trend or seasonality",
+			y:     []float64{5, 5, 5, 5, 5, 5, 5, 5},
+			m:     2,
+			h:     1,
+			alpha: 0.3,
+			beta:  0.1,
+			gamma: 0.1,
+			want:  5,
+		},
+		{
+			name:  "perfectly periodic series, one step ahead continues the pattern",
+			y:     []float64{1, 2, 3, 4, 1, 2, 3, 4, 1, 2, 3, 4},
+			m:     4,
+			h:     1,
+			alpha: 0.3,
+			beta:  0.1,
+			gamma: 0.1,
+			want:  1,
+		},
+		{
+			name:  "perfectly periodic series, h steps ahead wraps to the right phase",
+			y:     []float64{1, 2, 3, 4, 1, 2, 3, 4, 1, 2, 3, 4},
+			m:     4,
+			h:     4,
+			alpha: 0.3,
+			beta:  0.1,
+			gamma: 0.1,
+			want:  4,
+		},
+		{
+			name:  "too short for a seasonal fit falls back to double exponential smoothing",
+			y:     []float64{1, 2, 3, 4},
+			m:     3,
+			h:     2,
+			alpha: 0.3,
+			beta:  0.1,
+			gamma: 0.1,
+			want:  doubleExponentialSmoothing([]float64{1, 2, 3, 4}, 2, 0.3, 0.1),
+		},
+		{
+			name:  "non-positive seasonal period falls back instead of dividing by zero",
+			y:     []float64{1, 2, 3, 4, 5, 6},
+			m:     0,
+			h:     3,
+			alpha: 0.3,
+			beta:  0.1,
+			gamma: 0.1,
+			want:  doubleExponentialSmoothing([]float64{1, 2, 3, 4, 5, 6}, 3, 0.3, 0.1),
+		},
+		{
+			name:  "negative seasonal period falls back instead of dividing by zero",
+			y:     []float64{1, 2, 3, 4, 5, 6},
+			m:     -1,
+			h:     3,
+			alpha: 0.3,
+			beta:  0.1,
+			gamma: 0.1,
+			want:  doubleExponentialSmoothing([]float64{1, 2, 3, 4, 5, 6}, 3, 0.3, 0.1),
+		},
+		{
+			name: "empty series forecasts zero",
+			y:    nil,
+			m:    4,
+			h:    1,
+			want: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := holtWinters(tc.y, tc.m, tc.h, tc.alpha, tc.beta, tc.gamma)
+			if math.Abs(got-tc.want) > forecastTolerance {
+				t.Errorf("holtWinters(%v, m=%d, h=%d) = %v, want %v", tc.y, tc.m, tc.h, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// env variable for overriding the fetchDurationSeconds histogram buckets, a comma
+// separated list of floats (seconds), e.g. "0.1,0.5,1,5".
+const metricsBucketsKey = "PROM_METRICS_BUCKETS"
+
+var (
+	fetchAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "load_watcher",
+		Subsystem: "prometheus_provider",
+		Name:      "fetch_attempts_total",
+		Help:      "Number of upstream Prometheus queries attempted, by metric and aggregation method.",
+	}, []string{"metric", "method"})
+
+	fetchErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "load_watcher",
+		Subsystem: "prometheus_provider",
+		Name:      "fetch_errors_total",
+		Help:      "Number of upstream Prometheus queries that failed, by metric and aggregation method.",
+	}, []string{"metric", "method"})
+
+	fetchDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "load_watcher",
+		Subsystem: "prometheus_provider",
+		Name:      "fetch_duration_seconds",
+		Help:      "Latency of upstream Prometheus queries, by metric and aggregation method.",
+		Buckets:   queryLatencyBuckets(),
+	}, []string{"metric", "method"})
+
+	lastSuccessfulFetchTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "load_watcher",
+		Subsystem: "prometheus_provider",
+		Name:      "last_successful_fetch_timestamp_seconds",
+		Help:      "Unix timestamp of the last Prometheus query that succeeded.",
+	})
+)
+
+// queryLatencyBuckets returns the histogram buckets for fetchDurationSeconds, overridable
+// via PROM_METRICS_BUCKETS (mirroring Traefik's --web.metrics.prometheus.buckets flag).
+func queryLatencyBuckets() []float64 {
+	raw, present := os.LookupEnv(metricsBucketsKey)
+	if !present {
+		return prometheus.DefBuckets
+	}
+
+	parts := strings.Split(raw, ",")
+	buckets := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			log.Warnf("invalid bucket %q in %s, falling back to default buckets: %v", p, metricsBucketsKey, err)
+			return prometheus.DefBuckets
+		}
+		buckets = append(buckets, v)
+	}
+	return buckets
+}
+
+// NewMetricsHandler returns an http.Handler exposing load-watcher's own operational
+// metrics, for mounting alongside the watcher's /watcher endpoints.
+func NewMetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// observeFetch records the outcome of a single upstream Prometheus query for the given
+// metric/method pair: an attempt is always counted, an error is counted on failure, and
+// lastSuccessfulFetchTimestamp advances on success.
+func observeFetch(metric string, method string, start time.Time, err error) {
+	fetchAttemptsTotal.WithLabelValues(metric, method).Inc()
+	fetchDurationSeconds.WithLabelValues(metric, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		fetchErrorsTotal.WithLabelValues(metric, method).Inc()
+		return
+	}
+	lastSuccessfulFetchTimestamp.Set(float64(time.Now().Unix()))
+}
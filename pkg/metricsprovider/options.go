@@ -0,0 +1,165 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	promCAFileKey             = "PROM_CA_FILE"
+	promCertFileKey           = "PROM_CERT_FILE"
+	promKeyFileKey            = "PROM_KEY_FILE"
+	promInsecureSkipVerifyKey = "PROM_INSECURE_SKIP_VERIFY"
+	promBasicAuthUserKey      = "PROM_BASIC_AUTH_USERNAME"
+	promBasicAuthPasswordKey  = "PROM_BASIC_AUTH_PASSWORD"
+	promBearerTokenFileKey    = "PROM_TOKEN_FILE"
+	promMaxRetriesKey         = "PROM_MAX_RETRIES"
+	promRetryBaseDelayKey     = "PROM_RETRY_BASE_DELAY"
+
+	promMaxRetriesDefault     = 3
+	promRetryBaseDelayDefault = 500 * time.Millisecond
+)
+
+// PromClientOptions configures TLS, authentication and retry behaviour for NewPromClient
+// and NewPromForecastClient, mirroring the scrape config options kube-prometheus exposes
+// for connecting to a Prometheus server.
+type PromClientOptions struct {
+	// CAFile is a PEM bundle used to verify the Prometheus server's certificate. When
+	// set, it replaces the system roots rather than adding to them.
+	CAFile string
+	// CertFile and KeyFile configure mutual TLS. Both must be set together.
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables TLS certificate verification. Defaults to false;
+	// previously this was hardcoded to true with no way to opt out.
+	InsecureSkipVerify bool
+
+	// BasicAuthUsername and BasicAuthPassword configure HTTP basic auth. Ignored if
+	// BearerToken or BearerTokenFile is set.
+	BasicAuthUsername string
+	BasicAuthPassword string
+
+	// BearerToken is a static bearer token, sourced from PROM_TOKEN.
+	BearerToken string
+	// BearerTokenFile is re-read on every request, for deployments that rotate service
+	// account tokens (e.g. Kubernetes projected tokens) without restarting load-watcher.
+	BearerTokenFile string
+
+	// MaxRetries is the number of additional attempts made after a transient 5xx or
+	// network error, with exponential backoff starting at RetryBaseDelay.
+	MaxRetries int
+	RetryBaseDelay time.Duration
+}
+
+// DefaultPromClientOptions returns the options NewPromClient uses when none are read
+// from the environment.
+func DefaultPromClientOptions() PromClientOptions {
+	return PromClientOptions{
+		MaxRetries:     promMaxRetriesDefault,
+		RetryBaseDelay: promRetryBaseDelayDefault,
+	}
+}
+
+// promClientOptionsFromEnv builds PromClientOptions from the PROM_* environment
+// variables, falling back to DefaultPromClientOptions for anything unset.
+func promClientOptionsFromEnv() PromClientOptions {
+	opts := DefaultPromClientOptions()
+
+	opts.CAFile = os.Getenv(promCAFileKey)
+	opts.CertFile = os.Getenv(promCertFileKey)
+	opts.KeyFile = os.Getenv(promKeyFileKey)
+	opts.BasicAuthUsername = os.Getenv(promBasicAuthUserKey)
+	opts.BasicAuthPassword = os.Getenv(promBasicAuthPasswordKey)
+	opts.BearerToken = promToken
+	opts.BearerTokenFile = os.Getenv(promBearerTokenFileKey)
+
+	if raw, present := os.LookupEnv(promInsecureSkipVerifyKey); present {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Warnf("invalid value %q for %s, defaulting to false: %v", raw, promInsecureSkipVerifyKey, err)
+		} else {
+			opts.InsecureSkipVerify = v
+		}
+	}
+
+	if raw, present := os.LookupEnv(promMaxRetriesKey); present {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			log.Warnf("invalid value %q for %s, using default %d: %v", raw, promMaxRetriesKey, promMaxRetriesDefault, err)
+		} else {
+			opts.MaxRetries = v
+		}
+	}
+
+	opts.RetryBaseDelay = durationEnv(promRetryBaseDelayKey, promRetryBaseDelayDefault)
+
+	return opts
+}
+
+// tlsConfig builds the *tls.Config described by opts.
+func (opts PromClientOptions) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+
+	if opts.CAFile != "" {
+		caCert, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", promCAFileKey, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.CertFile != "" && opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate from %s/%s: %v", promCertFileKey, promKeyFileKey, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// authRoundTripper wraps next with whichever auth scheme opts configures, preferring a
+// bearer token (static or file-based) over basic auth, and returns next unchanged if
+// neither is configured.
+func (opts PromClientOptions) authRoundTripper(next http.RoundTripper) http.RoundTripper {
+	switch {
+	case opts.BearerTokenFile != "":
+		return config.NewAuthorizationCredentialsFileRoundTripper("Bearer", opts.BearerTokenFile, next)
+	case opts.BearerToken != "":
+		return config.NewAuthorizationCredentialsRoundTripper("Bearer", config.Secret(opts.BearerToken), next)
+	case opts.BasicAuthUsername != "":
+		return config.NewBasicAuthRoundTripper(opts.BasicAuthUsername, config.Secret(opts.BasicAuthPassword), "", "", next)
+	default:
+		return next
+	}
+}
@@ -17,155 +17,168 @@ limitations under the License.
 package metricsprovider
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
-	"github.com/paypal/load-watcher/pkg/watcher"
-	log "github.com/sirupsen/logrus"
 	"net/http"
 	"os"
-	"reflect"
-	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
 
 	// For out of cluster connections.
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 )
 
 var (
-	promHost    string
-	promToken	string
-	promTokenPresent = false
-	node_metric_query = map[string]string{
-		watcher.CPU : 	"instance:node_cpu:ratio",
-		watcher.Memory : "instance:node_memory_utilization:ratio",
-	}
-
+	promHost  string
+	promToken string
 )
 
 const (
 	// env variable that provides path to kube config file, if deploying from outside K8s cluster
-	promHostKey = "PROM_HOST"
-	promTokenKey = "PROM_TOKEN"
-	promQuery = "/api/v1/query?query="
+	promHostKey     = "PROM_HOST"
+	promTokenKey    = "PROM_TOKEN"
 	prom_std_method = "stddev_over_time"
 	prom_avg_method = "avg_over_time"
-	prom_cpu_metric = "instance:node_cpu:ratio"
-	prom_mem_metric = "instance:node_memory_utilisation:ratio"
+	// label used to identify the originating host in a prometheus result
+	hostLabel = "instance"
 )
 
 func init() {
 	var promHostPresent bool
 
 	promHost, promHostPresent = os.LookupEnv(promHostKey)
-	promToken, promTokenPresent = os.LookupEnv(promTokenKey)
+	promToken = os.Getenv(promTokenKey)
 	if !promHostPresent {
 		promHost = "prometheus-k8s:9090"
 	}
 }
 
 type promClient struct {
-	client http.Client
+	api     v1.API
+	metrics []MetricSpec
 }
 
 func NewPromClient() (watcher.FetcherClient, error) {
-	tlsConfig := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	promAPI, err := newPromAPI()
+	if err != nil {
+		return nil, err
 	}
-	return promClient{client: http.Client{
-		Timeout:   httpClientTimeout,
-		Transport: tlsConfig}}, nil
-}
-
-// Fetch all host metrics for all methods and resource types
-func (s promClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
-	hostMetrics := make(map[string][]watcher.Metric)
 
-	for _, method := range []string{prom_avg_method, prom_std_method} {
-		for _, metric := range []string{prom_cpu_metric, prom_mem_metric} {
-			hostMetrics = s.updateAllHostMetrics(hostMetrics, metric, method, window.Duration)
-		}
+	metricsConfig, err := loadMetricsConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	return hostMetrics, nil
+	return promClient{api: promAPI, metrics: metricsConfig.Metrics}, nil
 }
 
-// Fetch all host metrics for a particular method and resource type.
-func (s promClient) updateAllHostMetrics(hostMetrics map[string][]watcher.Metric, metric string, method string, rollup string) map[string][]watcher.Metric {
-	promURLStr := fmt.Sprintf("http://%s%s%s(%s[%s])", promHost,
-		promQuery, method, metric, rollup)
-	req, _ := http.NewRequest(http.MethodGet, promURLStr, nil)
-	req.Header.Set("Content-Type", "application/json")
+// newPromAPI builds the v1.API shared by promClient and promForecastClient, wiring up
+// TLS, auth and retry behaviour against promHost per PromClientOptions.
+func newPromAPI() (v1.API, error) {
+	opts := promClientOptionsFromEnv()
 
-	if promTokenPresent {
-		tokenStr := fmt.Sprintf("Bearer %s", promToken)
-		req.Header.Set("Authorization", tokenStr)
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	resp, _ := s.client.Do(req)
+	var roundTripper http.RoundTripper = &http.Transport{TLSClientConfig: tlsConfig}
+	roundTripper = opts.authRoundTripper(roundTripper)
+	roundTripper = newRetryRoundTripper(roundTripper, opts.MaxRetries, opts.RetryBaseDelay)
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("received status code: %v", resp.StatusCode)
-		return hostMetrics
+	// PROM_HOST may carry its own scheme (e.g. https://); a bare host:port uses http.
+	address := promHost
+	if !strings.Contains(address, "://") {
+		address = fmt.Sprintf("http://%s", address)
 	}
 
-	var res map[string]map[string]interface{}
-	err := json.NewDecoder(resp.Body).Decode(&res)
+	client, err := api.NewClient(api.Config{
+		Address:      address,
+		RoundTripper: roundTripper,
+	})
 	if err != nil {
-		log.Printf("error parsing the response: %v", err)
+		return nil, fmt.Errorf("unable to create prometheus client: %v", err)
 	}
 
-	if promdata, ok := res["data"]["result"]; ok {
-		log.Printf("receive response type: %v", reflect.TypeOf(promdata))
+	return v1.NewAPI(client), nil
+}
 
-		switch promdata.(type) {
-		case []interface{}:
-			fmt.Printf("response data is a slice of interface: %v \n ", promdata)
-			for _, prom_metric := range promdata.([]interface{}) { // use type assertion to loop over []interface{}
-				log.Printf("metric object: %v", prom_metric)
-				curMetric, curHost := promdata2metric(prom_metric.(map[string]interface{}), metric, method, rollup)
-				hostMetrics[curHost] = append(hostMetrics[curHost], curMetric)
+// FetchAllHostsMetrics fetches all host metrics for every configured MetricSpec and method.
+func (s promClient) FetchAllHostsMetrics(ctx context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
+	hostMetrics := make(map[string][]watcher.Metric)
+
+	for _, spec := range s.metrics {
+		for _, method := range spec.Methods {
+			promQL, err := spec.renderQuery(method, window.Duration)
+			if err != nil {
+				log.Errorf("%v", err)
+				continue
 			}
-		case map[string]interface{}:
-			fmt.Printf("%v is a slice of interface \n ", promdata)
-			curMetric, curHost := promdata2metric(promdata.(map[string]interface{}), metric, method, rollup)
-			hostMetrics[curHost] = append(hostMetrics[curHost], curMetric)
-		default:
-			log.Printf("%v is not recognized prometheus data format \n", promdata)
+			hostMetrics = s.updateAllHostMetrics(ctx, hostMetrics, spec, promQL, method, window.Duration)
 		}
-	} else {
-		log.Printf("not able to parse prometheus query response: %v", res)
 	}
 
-	return hostMetrics
+	return hostMetrics, nil
 }
 
-// Convert Json object from Prometheus query to watcher.Metric object.
-func promdata2metric(promdata map[string]interface{}, metric string, method string, rollup string) (watcher.Metric, string) {
-	var curMetric watcher.Metric
-	var curHost string
-	curMetric.Name = method
-	curMetric.Rollup = rollup
-
-	// TODO: define a consistent metric name and metric type across all types of clients.
-
-	if metric == prom_cpu_metric {
-		curMetric.Type = watcher.CPU
-	} else {
-		curMetric.Type = watcher.Memory
+// updateAllHostMetrics runs promQL and records a watcher.Metric named after method, for
+// every host found in the result, using spec to fill in the metric's type and host label.
+func (s promClient) updateAllHostMetrics(ctx context.Context, hostMetrics map[string][]watcher.Metric, spec MetricSpec, promQL string, method string, rollup string) map[string][]watcher.Metric {
+	start := time.Now()
+	result, warnings, err := s.api.Query(ctx, promQL, start)
+	observeFetch(spec.Name, method, start, err)
+	if err != nil {
+		log.Errorf("prometheus query %q failed: %v", promQL, err)
+		return hostMetrics
+	}
+	if len(warnings) > 0 {
+		log.Warnf("prometheus query %q returned warnings: %v", promQL, warnings)
 	}
 
-	for k, v := range promdata { // use type assertion to loop over []interface{}
-		log.Printf("metric key: %v", k)
-		log.Printf("metric value: %v", v)
-
-		if k == "metric" {
-			if _, ok := v.(map[string]interface {}); ok {
-				curHost = v.(map[string]interface {})["instance"].(string)
+	switch value := result.(type) {
+	case model.Vector:
+		for _, sample := range value {
+			curMetric, curHost := sampleToMetric(sample.Metric, float64(sample.Value), spec, method, rollup)
+			hostMetrics[curHost] = append(hostMetrics[curHost], curMetric)
+		}
+	case model.Matrix:
+		for _, stream := range value {
+			if len(stream.Values) == 0 {
+				continue
 			}
-		} else {
-			curMetric.Value, _ = strconv.ParseFloat(v.([]interface{})[1].(string), 64)
+			last := stream.Values[len(stream.Values)-1]
+			curMetric, curHost := sampleToMetric(stream.Metric, float64(last.Value), spec, method, rollup)
+			hostMetrics[curHost] = append(hostMetrics[curHost], curMetric)
 		}
+	case *model.Scalar:
+		log.Warnf("prometheus query %q returned a scalar with no host label, discarding", promQL)
+	default:
+		log.Warnf("prometheus query %q returned an unrecognized value type: %T", promQL, result)
 	}
 
-	return curMetric, curHost
-}
\ No newline at end of file
+	return hostMetrics
+}
+
+// sampleToMetric converts a prometheus label set and value into a watcher.Metric, along
+// with the host it was measured on.
+func sampleToMetric(labels model.Metric, value float64, spec MetricSpec, method string, rollup string) (watcher.Metric, string) {
+	curMetric := watcher.Metric{
+		Name:   method,
+		Type:   spec.Type,
+		Rollup: rollup,
+		Value:  value,
+	}
+
+	host := string(labels[model.LabelName(spec.HostLabel)])
+	if host == "" {
+		log.Warnf("metric %q: result is missing host label %q, value will be bucketed under an empty host", spec.Name, spec.HostLabel)
+	}
+
+	return curMetric, host
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// retryRoundTripper retries requests that fail with a transient network error or a 5xx
+// response, using exponential backoff starting at baseDelay.
+type retryRoundTripper struct {
+	next       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+func newRetryRoundTripper(next http.RoundTripper, maxRetries int, baseDelay time.Duration) http.RoundTripper {
+	if maxRetries <= 0 {
+		return next
+	}
+	return retryRoundTripper{next: next, maxRetries: maxRetries, baseDelay: baseDelay}
+}
+
+func (rt retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; attempt <= rt.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(rt.baseDelay * (1 << uint(attempt-1)))
+			// The previous attempt consumed the request body; resend a fresh copy of it.
+			if req.GetBody != nil {
+				body, bodyErr := req.GetBody()
+				if bodyErr != nil {
+					return nil, bodyErr
+				}
+				req = req.Clone(req.Context())
+				req.Body = body
+			}
+		}
+
+		resp, err = rt.next.RoundTrip(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+
+		if err != nil {
+			log.Warnf("prometheus request attempt %d/%d failed: %v", attempt+1, rt.maxRetries+1, err)
+			continue
+		}
+
+		log.Warnf("prometheus request attempt %d/%d received status %d", attempt+1, rt.maxRetries+1, resp.StatusCode)
+		if attempt == rt.maxRetries {
+			// This is the response we're about to return to the caller; leave its body
+			// intact so callers can still read it (e.g. client_golang populates
+			// Error.Detail from it).
+			break
+		}
+		// Drain and close the body of every failed response we're retrying past, so the
+		// underlying connection can be reused and we don't leak it.
+		_, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	return resp, err
+}
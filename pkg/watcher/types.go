@@ -0,0 +1,49 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import "context"
+
+// MetricType identifies the kind of resource a Metric measures.
+type MetricType string
+
+const (
+	CPU              MetricType = "CPU"
+	Memory           MetricType = "Memory"
+	GPU              MetricType = "GPU"
+	NetworkBandwidth MetricType = "NetworkBandwidth"
+	DiskIO           MetricType = "DiskIO"
+)
+
+// Metric is a single aggregated measurement for a host over a Window.
+type Metric struct {
+	Name   string
+	Type   MetricType
+	Rollup string
+	Value  float64
+}
+
+// Window describes the time range a set of Metrics were aggregated over.
+type Window struct {
+	Duration string
+}
+
+// FetcherClient is implemented by metrics providers that can report
+// per-host resource metrics for a given aggregation window.
+type FetcherClient interface {
+	FetchAllHostsMetrics(ctx context.Context, window *Window) (map[string][]Metric, error)
+}